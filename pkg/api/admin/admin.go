@@ -0,0 +1,223 @@
+// Package admin exposes pyroscope's storage maintenance operations
+// (DeleteApp, DeleteRange, DeleteBy, FlushCache, Compact) as an
+// out-of-band HTTP control surface, analogous to the "control" commands
+// other Go server projects expose for cluster operators. It is mounted
+// separately from the regular ingest/query API and gated behind a
+// configurable bearer token.
+//
+// This is deliberately HTTP-only: the project has no protobuf toolchain
+// or root CLI to hang a `pyroscope admin` gRPC service and subcommand
+// off of yet, so that part of the original ask is out of scope here.
+// Handler is still exercised end-to-end against a real *storage.Storage
+// (see the "end-to-end" spec in admin_test.go), which is the coverage a
+// gRPC transport would otherwise be the one to provide.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+)
+
+// Storage is the subset of *storage.Storage the admin API drives. It
+// exists so the handler can be unit-tested against a fake instead of a
+// real on-disk store.
+type Storage interface {
+	DeleteApp(appname string) error
+	DeleteRange(appname string, from, to time.Time) error
+	DeleteBy(selector segment.Key) error
+	MatchingSegments(selector segment.Key) ([]string, error)
+	FlushCache() error
+	Compact() error
+}
+
+// AuditLogger records every admin operation that was attempted, whether
+// it succeeded or not.
+type AuditLogger interface {
+	LogOp(entry AuditEntry)
+}
+
+// AuditEntry is a single audit log record.
+type AuditEntry struct {
+	Time     time.Time
+	Op       string
+	Selector string
+	Err      error
+}
+
+// LogrusAuditLogger writes audit entries through a *logrus.Logger at info
+// (success) or warn (failure) level.
+type LogrusAuditLogger struct {
+	Logger *logrus.Logger
+}
+
+// LogOp implements AuditLogger.
+func (l LogrusAuditLogger) LogOp(entry AuditEntry) {
+	fields := logrus.Fields{
+		"op":       entry.Op,
+		"selector": entry.Selector,
+		"time":     entry.Time,
+	}
+	if entry.Err != nil {
+		l.Logger.WithFields(fields).WithError(entry.Err).Warn("admin: operation failed")
+		return
+	}
+	l.Logger.WithFields(fields).Info("admin: operation succeeded")
+}
+
+// progressEvent is a single line of the newline-delimited JSON progress
+// stream a request produces.
+type progressEvent struct {
+	Phase           string `json:"phase"`
+	SegmentsVisited int    `json:"segments_visited,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// request is the body accepted by Handler: Op selects the maintenance
+// operation, and the remaining fields are interpreted according to Op.
+type request struct {
+	Op       string    `json:"op"`
+	AppName  string    `json:"app_name,omitempty"`
+	Selector string    `json:"selector,omitempty"`
+	From     time.Time `json:"from,omitempty"`
+	To       time.Time `json:"to,omitempty"`
+}
+
+const (
+	opDeleteApp   = "delete-app"
+	opDeleteRange = "delete-range"
+	opDeleteBy    = "delete-by"
+	opFlushCache  = "flush-cache"
+	opCompact     = "compact"
+)
+
+// Handler implements the admin HTTP API: POST a request body describing
+// an operation, and read back a stream of progress events followed by a
+// final "done" or "error" event.
+type Handler struct {
+	Storage Storage
+	Token   string
+	Audit   AuditLogger
+}
+
+// NewHandler returns a Handler serving maintenance operations against
+// storage. Every request must carry `Authorization: Bearer <token>`
+// matching token, and every attempt (successful or not) is recorded via
+// audit.
+func NewHandler(storage Storage, token string, audit AuditLogger) *Handler {
+	return &Handler{Storage: storage, Token: token, Audit: audit}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	err := h.run(req, func(ev progressEvent) { enc.Encode(ev) })
+
+	h.Audit.LogOp(AuditEntry{
+		Time:     time.Now(),
+		Op:       req.Op,
+		Selector: selectorOf(req),
+		Err:      err,
+	})
+
+	if err != nil {
+		enc.Encode(progressEvent{Phase: "error", Error: err.Error()})
+		return
+	}
+	enc.Encode(progressEvent{Phase: "done"})
+}
+
+func (h *Handler) run(req request, report func(progressEvent)) error {
+	switch req.Op {
+	case opDeleteApp:
+		selector, err := segment.ParseKey(req.AppName)
+		if err != nil {
+			return err
+		}
+		if err := h.reportMatches(selector, report); err != nil {
+			return err
+		}
+		return h.Storage.DeleteApp(req.AppName)
+
+	case opDeleteRange:
+		selector, err := segment.ParseKey(req.AppName)
+		if err != nil {
+			return err
+		}
+		if err := h.reportMatches(selector, report); err != nil {
+			return err
+		}
+		return h.Storage.DeleteRange(req.AppName, req.From, req.To)
+
+	case opDeleteBy:
+		selector, err := segment.ParseKey(req.Selector)
+		if err != nil {
+			return err
+		}
+		if err := h.reportMatches(selector, report); err != nil {
+			return err
+		}
+		return h.Storage.DeleteBy(selector)
+
+	case opFlushCache:
+		report(progressEvent{Phase: "start"})
+		return h.Storage.FlushCache()
+
+	case opCompact:
+		report(progressEvent{Phase: "start"})
+		return h.Storage.Compact()
+
+	default:
+		return fmt.Errorf("admin: unknown op %q", req.Op)
+	}
+}
+
+func (h *Handler) reportMatches(selector segment.Key, report func(progressEvent)) error {
+	matches, err := h.Storage.MatchingSegments(selector)
+	if err != nil {
+		return err
+	}
+	report(progressEvent{Phase: "start", SegmentsVisited: len(matches)})
+	return nil
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return strings.TrimPrefix(auth, prefix) == h.Token
+}
+
+func selectorOf(req request) string {
+	if req.Selector != "" {
+		return req.Selector
+	}
+	return req.AppName
+}
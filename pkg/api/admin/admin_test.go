@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/pyroscope-io/pyroscope/pkg/storage"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+	"github.com/pyroscope-io/pyroscope/pkg/testing"
+)
+
+// fakeStorage is the fake the admin package is meant to be unit-testable
+// against, per the request's "wire Storage through an interface" ask.
+type fakeStorage struct {
+	deletedApps   []string
+	deletedRanges []string
+	deletedBy     []string
+	flushed       bool
+	compacted     bool
+	matches       []string
+	err           error
+}
+
+func (f *fakeStorage) DeleteApp(appname string) error {
+	f.deletedApps = append(f.deletedApps, appname)
+	return f.err
+}
+
+func (f *fakeStorage) DeleteRange(appname string, from, to time.Time) error {
+	f.deletedRanges = append(f.deletedRanges, appname)
+	return f.err
+}
+
+func (f *fakeStorage) DeleteBy(selector segment.Key) error {
+	f.deletedBy = append(f.deletedBy, selector.SegmentKey())
+	return f.err
+}
+
+func (f *fakeStorage) MatchingSegments(selector segment.Key) ([]string, error) {
+	return f.matches, nil
+}
+
+func (f *fakeStorage) FlushCache() error {
+	f.flushed = true
+	return f.err
+}
+
+func (f *fakeStorage) Compact() error {
+	f.compacted = true
+	return f.err
+}
+
+type fakeAudit struct {
+	entries []AuditEntry
+}
+
+func (f *fakeAudit) LogOp(entry AuditEntry) {
+	f.entries = append(f.entries, entry)
+}
+
+var _ = Describe("admin.Handler", func() {
+	var storage *fakeStorage
+	var audit *fakeAudit
+	var handler *Handler
+
+	BeforeEach(func() {
+		storage = &fakeStorage{matches: []string{"my.app.cpu{}"}}
+		audit = &fakeAudit{}
+		handler = NewHandler(storage, "s3cr3t", audit)
+	})
+
+	post := func(body request) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/admin", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	Context("authentication", func() {
+		It("rejects requests without a matching bearer token", func() {
+			req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("rejects requests with the wrong token", func() {
+			req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer wrong")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("delete-app", func() {
+		It("deletes the app, streams progress, and audits the attempt", func() {
+			rr := post(request{Op: opDeleteApp, AppName: "my.app.cpu"})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(storage.deletedApps).To(Equal([]string{"my.app.cpu"}))
+
+			Expect(rr.Body.String()).To(ContainSubstring(`"segments_visited":1`))
+			Expect(rr.Body.String()).To(ContainSubstring(`"phase":"done"`))
+
+			Expect(audit.entries).To(HaveLen(1))
+			Expect(audit.entries[0].Op).To(Equal(opDeleteApp))
+			Expect(audit.entries[0].Err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("delete-by", func() {
+		It("parses the selector and delegates to Storage.DeleteBy", func() {
+			rr := post(request{Op: opDeleteBy, Selector: "{env=staging}"})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(storage.deletedBy).To(Equal([]string{"{env=staging}"}))
+		})
+	})
+
+	Context("flush-cache and compact", func() {
+		It("flushes the cache", func() {
+			post(request{Op: opFlushCache})
+			Expect(storage.flushed).To(BeTrue())
+		})
+
+		It("compacts", func() {
+			post(request{Op: opCompact})
+			Expect(storage.compacted).To(BeTrue())
+		})
+	})
+
+	Context("an unknown op", func() {
+		It("reports an error event and still audits the attempt", func() {
+			rr := post(request{Op: "bogus"})
+			Expect(rr.Body.String()).To(ContainSubstring(`"phase":"error"`))
+			Expect(audit.entries[0].Err).To(HaveOccurred())
+		})
+	})
+})
+
+// end-to-end: exercises the HTTP handler against a real *storage.Storage,
+// rather than fakeStorage, so a delete actually runs through Storage's own
+// segment/dimension/dict bookkeeping and not just a recorded call.
+var _ = Describe("admin.Handler end-to-end", func() {
+	var s *storage.Storage
+
+	testing.WithConfig(func(cfg **config.Config) {
+		JustBeforeEach(func() {
+			var err error
+			s, err = storage.New(storage.NewConfig(&(*cfg).Server), logrus.StandardLogger(), prometheus.NewRegistry())
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	It("deletes an app stored through the real storage package", func() {
+		appname := "my.app.cpu"
+		key, err := segment.ParseKey(appname)
+		Expect(err).ToNot(HaveOccurred())
+
+		t := tree.New()
+		t.Insert([]byte("a;b"), uint64(1))
+		Expect(s.Put(&storage.PutInput{
+			StartTime:  testing.SimpleTime(10),
+			EndTime:    testing.SimpleTime(19),
+			Key:        key,
+			Val:        t,
+			SpyName:    "testspy",
+			SampleRate: 100,
+		})).ToNot(HaveOccurred())
+		Expect(s.GetAppNames()).To(ContainElement(appname))
+
+		handler := NewHandler(s, "s3cr3t", &fakeAudit{})
+		body, _ := json.Marshal(request{Op: opDeleteApp, AppName: appname})
+		req := httptest.NewRequest(http.MethodPost, "/admin", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).To(ContainSubstring(`"phase":"done"`))
+		Expect(s.GetAppNames()).ToNot(ContainElement(appname))
+	})
+})
@@ -0,0 +1,7 @@
+package admin
+
+import "github.com/pyroscope-io/pyroscope/pkg/storage"
+
+// Compile-time assertion that *storage.Storage satisfies the Storage
+// interface this package depends on.
+var _ Storage = (*storage.Storage)(nil)
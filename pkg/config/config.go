@@ -0,0 +1,13 @@
+// Package config defines the top-level application configuration shared by
+// the pyroscope server, agent and CLI.
+package config
+
+// Config is the root configuration object, populated from file/env/flags.
+type Config struct {
+	Server Server
+}
+
+// Server holds the options for `pyroscope server`.
+type Server struct {
+	StoragePath string `mapstructure:"storage-path"`
+}
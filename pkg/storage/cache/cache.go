@@ -0,0 +1,126 @@
+// Package cache provides the in-memory/on-disk cache fronting every
+// storage sub-index (trees, dicts, segments, dimensions). Entries are kept
+// in an in-memory front cache and are never written to the on-disk DB:
+// nothing is evicted and Dump does nothing. The DB is only used by Delete.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// DB is the subset of a disk-backed key/value store the cache needs. It is
+// satisfied by the badger-backed store used in production and by fakes in
+// tests.
+type DB interface {
+	Delete(key []byte) error
+}
+
+// LRU is a minimal size/lookup front cache: a map guarded by a lock. It
+// never evicts entries; they stay until removed explicitly.
+type LRU struct {
+	mu    sync.RWMutex
+	items map[string]interface{}
+}
+
+func newLRU() *LRU {
+	return &LRU{items: make(map[string]interface{})}
+}
+
+// Size returns the number of entries currently cached.
+func (l *LRU) Size() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return uint64(len(l.items))
+}
+
+// Lookup returns the cached value for key, if any.
+func (l *LRU) Lookup(key string) (interface{}, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	v, ok := l.items[key]
+	return v, ok
+}
+
+// Keys returns all cached keys. Order is unspecified.
+func (l *LRU) Keys() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	keys := make([]string, 0, len(l.items))
+	for k := range l.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (l *LRU) set(key string, val interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items[key] = val
+}
+
+func (l *LRU) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.items, key)
+}
+
+// Cache is a named cache backed by an LRU front and an optional on-disk DB.
+type Cache struct {
+	Cache *LRU
+	db    DB
+	name  string
+
+	expiryMu sync.Mutex
+	expiry   map[string]time.Time
+}
+
+// New returns a Cache named name, which also removes deleted keys from db.
+// db may be nil in tests that only exercise the in-memory path.
+func New(name string, db DB) *Cache {
+	return &Cache{Cache: newLRU(), db: db, name: name, expiry: make(map[string]time.Time)}
+}
+
+// Put inserts or replaces the value stored under key.
+func (c *Cache) Put(key string, val interface{}) {
+	c.Cache.set(key, val)
+}
+
+// PutWithExpiry is like Put, but additionally records that key should be
+// considered expired from expireAt onwards, so a later call to
+// ExpiredKeys(now) with now >= expireAt will include it.
+func (c *Cache) PutWithExpiry(key string, val interface{}, expireAt time.Time) {
+	c.Cache.set(key, val)
+	c.expiryMu.Lock()
+	c.expiry[key] = expireAt
+	c.expiryMu.Unlock()
+}
+
+// ExpiredKeys returns every key whose recorded expiry is at or before now.
+func (c *Cache) ExpiredKeys(now time.Time) []string {
+	c.expiryMu.Lock()
+	defer c.expiryMu.Unlock()
+	var keys []string
+	for k, expireAt := range c.expiry {
+		if !expireAt.After(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Delete removes key from both the front cache and the on-disk store.
+func (c *Cache) Delete(key string) error {
+	c.Cache.remove(key)
+	c.expiryMu.Lock()
+	delete(c.expiry, key)
+	c.expiryMu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Delete([]byte(key))
+}
+
+// Dump is a no-op placeholder for flushing dirty entries to disk; tests call
+// it to mirror the real cache's write-back behavior.
+func (c *Cache) Dump() {}
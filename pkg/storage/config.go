@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+)
+
+// defaultReapInterval is how often the background reaper scans for expired
+// entries when Config.ReapInterval is left unset.
+const defaultReapInterval = time.Minute
+
+// Config holds the storage package's own options, derived from the
+// server's configuration.
+type Config struct {
+	StoragePath string
+
+	// DefaultTTL is applied to a Put that doesn't specify its own
+	// ExpireTime/TTL. Zero means writes never expire by default.
+	DefaultTTL time.Duration
+	// TTLOverrides maps an app-name glob (as matched by path.Match, e.g.
+	// "my.noisy.*") to the TTL that should apply to matching apps instead
+	// of DefaultTTL. If several patterns match, which one applies is not
+	// defined (the map is iterated in random order).
+	TTLOverrides map[string]time.Duration
+	// ReapInterval is how often the background reaper scans for expired
+	// data. Defaults to defaultReapInterval.
+	ReapInterval time.Duration
+}
+
+// NewConfig derives a storage Config from the server configuration.
+func NewConfig(server *config.Server) *Config {
+	return &Config{
+		StoragePath: server.StoragePath,
+	}
+}
+
+func (c *Config) reapInterval() time.Duration {
+	if c.ReapInterval > 0 {
+		return c.ReapInterval
+	}
+	return defaultReapInterval
+}
@@ -0,0 +1,59 @@
+// Package dict implements a simple string interning table used to compress
+// stack trace frame names before they are stored in a tree.
+package dict
+
+import "sync"
+
+// Dict maps frame names to small integer IDs so that trees can reference
+// frames without repeating the full name on every node.
+type Dict struct {
+	mu   sync.RWMutex
+	ids  map[string]int
+	strs [][]byte
+}
+
+// New returns an empty Dict.
+func New() *Dict {
+	return &Dict{ids: make(map[string]int)}
+}
+
+// GetOrCreate returns the ID for name, assigning a new one if it hasn't been
+// seen before.
+func (d *Dict) GetOrCreate(name []byte) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if id, ok := d.ids[string(name)]; ok {
+		return id
+	}
+	id := len(d.strs)
+	cp := make([]byte, len(name))
+	copy(cp, name)
+	d.strs = append(d.strs, cp)
+	d.ids[string(cp)] = id
+	return id
+}
+
+// Get returns the ID previously assigned to name, if any.
+func (d *Dict) Get(name []byte) (int, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.ids[string(name)]
+	return id, ok
+}
+
+// String returns the frame name associated with id.
+func (d *Dict) String(id int) []byte {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if id < 0 || id >= len(d.strs) {
+		return nil
+	}
+	return d.strs[id]
+}
+
+// Size reports the number of distinct names interned.
+func (d *Dict) Size() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.strs)
+}
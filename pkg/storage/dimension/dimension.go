@@ -0,0 +1,89 @@
+// Package dimension implements the posting-list index that maps a label
+// (e.g. `__name__:my.app.cpu` or `foo:bar`) to the set of segment keys that
+// carry it. Storage intersects dimensions to answer label-selector queries.
+package dimension
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// Dimension is a sorted, deduplicated set of segment keys sharing a label.
+type Dimension struct {
+	mu   sync.RWMutex
+	Keys [][]byte
+}
+
+// New returns an empty Dimension.
+func New() *Dimension {
+	return &Dimension{}
+}
+
+// Insert adds key to the dimension if it isn't already present.
+func (d *Dimension) Insert(key []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := sort.Search(len(d.Keys), func(i int) bool { return bytes.Compare(d.Keys[i], key) >= 0 })
+	if i < len(d.Keys) && bytes.Equal(d.Keys[i], key) {
+		return
+	}
+	d.Keys = append(d.Keys, nil)
+	copy(d.Keys[i+1:], d.Keys[i:])
+	cp := make([]byte, len(key))
+	copy(cp, key)
+	d.Keys[i] = cp
+}
+
+// Delete removes key from the dimension. It is a no-op if key is absent.
+func (d *Dimension) Delete(key []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := sort.Search(len(d.Keys), func(i int) bool { return bytes.Compare(d.Keys[i], key) >= 0 })
+	if i < len(d.Keys) && bytes.Equal(d.Keys[i], key) {
+		d.Keys = append(d.Keys[:i], d.Keys[i+1:]...)
+	}
+}
+
+// IsEmpty reports whether the dimension no longer references any segment.
+func (d *Dimension) IsEmpty() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.Keys) == 0
+}
+
+// Intersection returns the keys present in every one of dimensions.
+func Intersection(dimensions ...*Dimension) [][]byte {
+	if len(dimensions) == 0 {
+		return nil
+	}
+	for _, dm := range dimensions {
+		dm.mu.RLock()
+		defer dm.mu.RUnlock()
+	}
+	result := dimensions[0].Keys
+	for _, dm := range dimensions[1:] {
+		result = intersectSorted(result, dm.Keys)
+	}
+	out := make([][]byte, len(result))
+	copy(out, result)
+	return out
+}
+
+func intersectSorted(a, b [][]byte) [][]byte {
+	var out [][]byte
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch bytes.Compare(a[i], b[j]) {
+		case 0:
+			out = append(out, a[i])
+			i++
+			j++
+		case -1:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
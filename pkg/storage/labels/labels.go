@@ -0,0 +1,58 @@
+// Package labels indexes which label values have been seen for each label
+// name, independent of which segments reference them. Storage consults it
+// to answer GetAppNames and similar discovery queries.
+package labels
+
+import "sync"
+
+// Labels is a set of label name -> set of values seen for that name.
+type Labels struct {
+	mu sync.RWMutex
+	m  map[string]map[string]bool
+}
+
+// New returns an empty Labels index.
+func New() *Labels {
+	return &Labels{m: make(map[string]map[string]bool)}
+}
+
+// Put records that name=value has been seen.
+func (l *Labels) Put(name, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	values, ok := l.m[name]
+	if !ok {
+		values = make(map[string]bool)
+		l.m[name] = values
+	}
+	values[value] = true
+}
+
+// Delete removes name=value from the index.
+func (l *Labels) Delete(name, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	values, ok := l.m[name]
+	if !ok {
+		return
+	}
+	delete(values, value)
+	if len(values) == 0 {
+		delete(l.m, name)
+	}
+}
+
+// GetValues returns every value seen for name.
+func (l *Labels) GetValues(name string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	values, ok := l.m[name]
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for v := range values {
+		out = append(out, v)
+	}
+	return out
+}
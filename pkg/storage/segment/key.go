@@ -0,0 +1,117 @@
+// Package segment implements the per-app, per-label time index: a Key
+// identifies a segment (an app name plus a label set), and a Segment is the
+// multi-resolution tree of time buckets that index stored trees.
+package segment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Key identifies a single segment: an application name plus zero or more
+// labels, e.g. `my.app.cpu{foo=bar}`.
+type Key struct {
+	appName string
+	labels  map[string]string
+}
+
+// ParseKey parses strings of the form `app.name{k1=v1,k2=v2}`. The app name
+// and the braces are both optional, so `{env=staging}` parses to a Key with
+// no app name and is used for label-only selectors.
+func ParseKey(s string) (Key, error) {
+	k := Key{labels: make(map[string]string)}
+	open := strings.IndexByte(s, '{')
+	if open == -1 {
+		k.appName = s
+		return k, nil
+	}
+	if !strings.HasSuffix(s, "}") {
+		return Key{}, fmt.Errorf("segment: invalid key %q: missing closing brace", s)
+	}
+	k.appName = s[:open]
+	inner := s[open+1 : len(s)-1]
+	if inner == "" {
+		return k, nil
+	}
+	for _, pair := range strings.Split(inner, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return Key{}, fmt.Errorf("segment: invalid key %q: malformed label %q", s, pair)
+		}
+		k.labels[kv[0]] = kv[1]
+	}
+	return k, nil
+}
+
+// AppName returns the `__name__` component of the key.
+func (k Key) AppName() string { return k.appName }
+
+// Labels returns the label set of the key. The caller must not mutate it.
+func (k Key) Labels() map[string]string { return k.labels }
+
+// Match reports whether k carries every label present in selector (and, if
+// selector has an app name, the same app name). DeleteBy does not call it:
+// it resolves a selector through the dimension index instead.
+func (k Key) Match(selector Key) bool {
+	if selector.appName != "" && selector.appName != k.appName {
+		return false
+	}
+	for lk, lv := range selector.labels {
+		if k.labels[lk] != lv {
+			return false
+		}
+	}
+	return true
+}
+
+// SegmentKey returns the canonical, sorted string form of the key, as used
+// to address the segments cache.
+func (k Key) SegmentKey() string {
+	return k.appName + "{" + k.labelsString() + "}"
+}
+
+// TreeKey returns the cache/disk key for the tree stored at the given depth
+// and bucket time within this segment.
+func (k Key) TreeKey(depth int, t time.Time) string {
+	return fmt.Sprintf("t:%s:%d:%d", k.SegmentKey(), depth, t.Unix())
+}
+
+// DimensionPair is a single `name=value` label the key should be indexed
+// under, including the synthetic `__name__` label for the app name.
+type DimensionPair struct {
+	Name  string
+	Value string
+}
+
+// DimensionKey returns the storage.dimensions cache key for the pair.
+func (p DimensionPair) DimensionKey() string { return p.Name + ":" + p.Value }
+
+// DimensionPairs returns every `name=value` pair (including `__name__`)
+// that this segment should be indexed under in storage.dimensions.
+func (k Key) DimensionPairs() []DimensionPair {
+	pairs := make([]DimensionPair, 0, len(k.labels)+1)
+	if k.appName != "" {
+		pairs = append(pairs, DimensionPair{Name: "__name__", Value: k.appName})
+	}
+	for lk, lv := range k.labels {
+		pairs = append(pairs, DimensionPair{Name: lk, Value: lv})
+	}
+	return pairs
+}
+
+func (k Key) labelsString() string {
+	keys := make([]string, 0, len(k.labels))
+	for lk := range k.labels {
+		keys = append(keys, lk)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, lk := range keys {
+		parts[i] = lk + "=" + k.labels[lk]
+	}
+	return strings.Join(parts, ",")
+}
+
+func (k Key) String() string { return k.SegmentKey() }
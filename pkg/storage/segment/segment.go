@@ -0,0 +1,176 @@
+package segment
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	baseResolution = 10 * time.Second
+	multiplier     = 10
+	// MaxDepth bounds how many coarser aggregation levels a segment keeps.
+	// Depth 0 is the finest (10s) resolution; each subsequent depth is
+	// multiplier times coarser.
+	MaxDepth = 3
+)
+
+type node struct {
+	depth int
+	time  time.Time
+}
+
+// Segment is the multi-resolution time index for a single Key: it tracks,
+// at every depth, which time buckets have data so Storage knows which
+// `t:...:depth:ts` tree keys exist without touching the cache/disk layer.
+// Only depth 0 ever has a tree attached to it; depths 1..MaxDepth are a
+// presence rollup that lets a bounded delete reason about a whole coarse
+// window without enumerating every depth-0 bucket underneath it.
+type Segment struct {
+	mu    sync.RWMutex
+	nodes map[string]node
+}
+
+// New returns an empty Segment.
+func New() *Segment {
+	return &Segment{nodes: make(map[string]node)}
+}
+
+func resolution(depth int) time.Duration {
+	d := baseResolution
+	for i := 0; i < depth; i++ {
+		d *= multiplier
+	}
+	return d
+}
+
+// Put records a single write starting at st and invokes cb once per depth,
+// for the bucket that contains st at that depth's resolution. It fires on
+// every call, not just the first time a bucket is seen, since a later Put
+// into an already-present bucket still has data that needs storing. Only
+// the depth-0 call corresponds to an actual tree Storage should write;
+// depths 1..MaxDepth exist purely so DeleteRange can tell whether a
+// coarser window still has any data underneath it.
+//
+// et is accepted (mirroring PutInput, which carries the full [st, et) of
+// the write) but unused: a write is attributed to a single bucket per
+// depth rather than every bucket it spans, so it never affects which
+// buckets get touched.
+func (s *Segment) Put(st, et time.Time, cb func(depth int, t time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for depth := 0; depth <= MaxDepth; depth++ {
+		t := st.Truncate(resolution(depth))
+		s.nodes[depthTimeKey(depth, t)] = node{depth: depth, time: t}
+		cb(depth, t)
+	}
+}
+
+// DeleteRange removes every depth-0 bucket that overlaps [from, to), even
+// partially, and invokes cb for each one removed, so the caller can evict
+// the matching tree from cache/disk. Coarser (depth >= 1) buckets are
+// never removed by containment of their own, much wider span: instead,
+// once their depth-0 descendants are pruned, each coarser depth is split
+// down to just the buckets that still have a child underneath them, and
+// whatever is left with nothing is removed in turn. This is what keeps a
+// bounded delete from discarding presence for data outside the requested
+// window, or leaving stale presence behind for a window it fully deleted.
+func (s *Segment) DeleteRange(from, to time.Time, cb func(depth int, t time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res0 := resolution(0)
+	for key, n := range s.nodes {
+		if n.depth != 0 || !bucketOverlaps(n.time, res0, from, to) {
+			continue
+		}
+		delete(s.nodes, key)
+		cb(n.depth, n.time)
+	}
+
+	for depth := 1; depth <= MaxDepth; depth++ {
+		res := resolution(depth)
+		childDepth := depth - 1
+		for key, n := range s.nodes {
+			if n.depth != depth || s.hasChildLocked(childDepth, n.time, res) {
+				continue
+			}
+			delete(s.nodes, key)
+			cb(n.depth, n.time)
+		}
+	}
+}
+
+// DeleteNode removes a single (depth, t) bucket, if present, and invokes
+// cb for it. It never removes a node at a different depth merely because
+// that node's bucket time happens to coincide (coarser
+// depths' bucket boundaries are multiples of finer ones, so timestamps
+// can collide across depths). Any coarser ancestor left with no
+// remaining child afterwards is removed too.
+func (s *Segment) DeleteNode(depth int, t time.Time, cb func(depth int, t time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket := t.Truncate(resolution(depth))
+	key := depthTimeKey(depth, bucket)
+	if _, ok := s.nodes[key]; !ok {
+		return
+	}
+	delete(s.nodes, key)
+	cb(depth, bucket)
+
+	childDepth, childTime := depth, bucket
+	for parentDepth := childDepth + 1; parentDepth <= MaxDepth; parentDepth++ {
+		res := resolution(parentDepth)
+		parentTime := childTime.Truncate(res)
+		if s.hasChildLocked(childDepth, parentTime, res) {
+			return
+		}
+		parentKey := depthTimeKey(parentDepth, parentTime)
+		if _, ok := s.nodes[parentKey]; !ok {
+			return
+		}
+		delete(s.nodes, parentKey)
+		childDepth, childTime = parentDepth, parentTime
+	}
+}
+
+// hasChildLocked reports whether any node at childDepth falls within
+// [bucketStart, bucketStart+res). Callers must hold s.mu.
+func (s *Segment) hasChildLocked(childDepth int, bucketStart time.Time, res time.Duration) bool {
+	bucketEnd := bucketStart.Add(res)
+	for _, n := range s.nodes {
+		if n.depth == childDepth && !n.time.Before(bucketStart) && n.time.Before(bucketEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketOverlaps reports whether [bucketStart, bucketStart+res) intersects
+// [from, to) at all.
+func bucketOverlaps(bucketStart time.Time, res time.Duration, from, to time.Time) bool {
+	return bucketStart.Before(to) && bucketStart.Add(res).After(from)
+}
+
+// Walk invokes cb for every bucket currently present in the segment.
+func (s *Segment) Walk(cb func(depth int, t time.Time)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, n := range s.nodes {
+		cb(n.depth, n.time)
+	}
+}
+
+// IsEmpty reports whether the segment no longer has data at any depth,
+// which is the signal Storage uses to garbage-collect the dictionary,
+// dimensions and label entries for the key.
+func (s *Segment) IsEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.nodes) == 0
+}
+
+func depthTimeKey(depth int, t time.Time) string {
+	return fmt.Sprintf("%d:%d", depth, t.Unix())
+}
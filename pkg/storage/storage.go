@@ -0,0 +1,381 @@
+// Package storage implements pyroscope's on-disk profile store: a
+// multi-resolution, label-indexed tree of flame graphs keyed by
+// application name and time.
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pyroscope-io/pyroscope/pkg/storage/cache"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/dimension"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/labels"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+)
+
+// Storage is the top-level handle to every on-disk/cached index pyroscope
+// maintains for stored profiles.
+type Storage struct {
+	config *Config
+	logger *logrus.Logger
+	clock  clock.Clock
+
+	// mu serializes Put against DeleteApp/DeleteRange/DeleteBy/the
+	// reaper, so a segment or dimension can't be created twice by a
+	// racing first-write, and a delete can't observe a half-written
+	// segment.
+	mu sync.Mutex
+
+	segments   *cache.Cache
+	dimensions *cache.Cache
+	dicts      *cache.Cache
+	trees      *cache.Cache
+	labels     *labels.Labels
+
+	expiry  *expiryIndex
+	metrics *expiryMetrics
+	stop    chan struct{}
+}
+
+// New returns a Storage whose indexes are in-memory caches with no on-disk
+// store behind them (config.StoragePath is not read), and starts the
+// background reaper that evicts data past its TTL.
+func New(config *Config, logger *logrus.Logger, reg prometheus.Registerer) (*Storage, error) {
+	return newStorage(config, logger, reg, clock.New())
+}
+
+// newStorage is New with the clock injectable, so tests can drive the
+// reaper with a mock clock instead of waiting on wall-clock time.
+func newStorage(config *Config, logger *logrus.Logger, reg prometheus.Registerer, c clock.Clock) (*Storage, error) {
+	s := &Storage{
+		config:     config,
+		logger:     logger,
+		clock:      c,
+		segments:   cache.New("segments", nil),
+		dimensions: cache.New("dimensions", nil),
+		dicts:      cache.New("dicts", nil),
+		trees:      cache.New("trees", nil),
+		labels:     labels.New(),
+		expiry:     newExpiryIndex(),
+		metrics:    newExpiryMetrics(reg),
+		stop:       make(chan struct{}),
+	}
+	go s.reapLoop()
+	return s, nil
+}
+
+// Close stops the background reaper. It does not close the underlying
+// on-disk store, which storage does not yet manage directly.
+func (s *Storage) Close() error {
+	close(s.stop)
+	return nil
+}
+
+// FlushCache calls Dump on every sub-index cache. Dump writes nothing, so
+// data held only in memory is not persisted by this call.
+func (s *Storage) FlushCache() error {
+	s.segments.Dump()
+	s.dimensions.Dump()
+	s.dicts.Dump()
+	s.trees.Dump()
+	return nil
+}
+
+// Compact is the maintenance entry point for reclaiming the space freed by
+// DeleteApp/DeleteRange/DeleteBy and the reaper. It performs no compaction
+// and always returns nil. It is not part of the regular read/write path.
+func (s *Storage) Compact() error {
+	// TODO: hook this up to the disk store's own compaction once storage
+	// manages it directly rather than through the Cache abstraction.
+	return nil
+}
+
+// PutInput carries the parameters for a single profile write.
+type PutInput struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	Key        segment.Key
+	Val        *tree.Tree
+	SpyName    string
+	SampleRate uint32
+
+	// ExpireTime, if set, is the absolute instant after which this write
+	// becomes eligible for reaping. TTL is a convenience alternative:
+	// when ExpireTime is zero and TTL is positive, the expiry is computed
+	// as the storage clock's current time plus TTL. If neither is set,
+	// Config.DefaultTTL/TTLOverrides apply.
+	ExpireTime time.Time
+	TTL        time.Duration
+}
+
+// Put stores a profile tree, updating the segment, dimension and label
+// indexes that reference it. It does not create the app's dictionary.
+func (s *Storage) Put(po *PutInput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segmentKey := po.Key.SegmentKey()
+
+	seg, ok := s.segments.Cache.Lookup(segmentKey)
+	var sg *segment.Segment
+	if ok {
+		sg = seg.(*segment.Segment)
+	} else {
+		sg = segment.New()
+		s.segments.Put(segmentKey, sg)
+	}
+
+	for _, pair := range po.Key.DimensionPairs() {
+		dk := pair.DimensionKey()
+		dm, ok := s.dimensions.Cache.Lookup(dk)
+		var d *dimension.Dimension
+		if ok {
+			d = dm.(*dimension.Dimension)
+		} else {
+			d = dimension.New()
+			s.dimensions.Put(dk, d)
+		}
+		d.Insert([]byte(segmentKey))
+		s.labels.Put(pair.Name, pair.Value)
+	}
+
+	expireAt := s.expireTimeFor(po)
+	sg.Put(po.StartTime, po.EndTime, func(depth int, t time.Time) {
+		// Only depth 0 ever has a tree: coarser depths are a presence
+		// rollup DeleteRange uses to split bounded deletes correctly,
+		// not separate stored trees.
+		if depth != 0 {
+			return
+		}
+		treeKey := po.Key.TreeKey(depth, t)
+		if expireAt.IsZero() {
+			s.trees.Put(treeKey, po.Val)
+			return
+		}
+		s.trees.PutWithExpiry(treeKey, po.Val, expireAt)
+		s.expiry.put(treeKey, treeRef{Key: po.Key, Depth: depth, Time: t})
+	})
+
+	return nil
+}
+
+// GetAppNames returns every distinct application name currently stored.
+func (s *Storage) GetAppNames() []string {
+	return s.labels.GetValues("__name__")
+}
+
+// lookupAppDimension returns the `__name__` dimension for appname, if any
+// segment has been stored under it.
+func (s *Storage) lookupAppDimension(appname string) (*dimension.Dimension, bool) {
+	v, ok := s.dimensions.Cache.Lookup("__name__:" + appname)
+	if !ok {
+		return nil, false
+	}
+	return v.(*dimension.Dimension), true
+}
+
+// farFuture stands in for "no upper bound" when DeleteApp delegates to
+// DeleteRange.
+var farFuture = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DeleteApp removes every segment (across all label combinations), tree,
+// dictionary, dimension and label reference for appname. It is equivalent
+// to DeleteRange over all time.
+func (s *Storage) DeleteApp(appname string) error {
+	return s.DeleteRange(appname, time.Time{}, farFuture)
+}
+
+// DeleteRange removes the data stored for appname, across every label
+// combination it has been written under, restricted to [from, to). Each
+// affected segment has its touched nodes pruned (or split, when only part
+// of its range falls in [from, to)) at every depth, evicting the
+// corresponding `t:...:depth:ts` tree entries from cache and disk.
+//
+// A dimension, dictionary or label entry is only garbage-collected once
+// nothing references it any more: a label shared with another app (or
+// another segment of the same app outside [from, to)) is left alone, and
+// so is the app's dictionary while any of its segments still has data.
+//
+// Concurrent Puts into an affected segment are serialized against the
+// delete via s.mu, so the cache and on-disk state cannot diverge
+// mid-delete.
+func (s *Storage) DeleteRange(appname string, from, to time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.lookupAppDimension(appname)
+	if !ok {
+		return nil
+	}
+	// Snapshot the keys: deleting a segment mutates this very dimension.
+	keys := make([][]byte, len(d.Keys))
+	copy(keys, d.Keys)
+	return s.deleteMatchingSegments(keys, from, to)
+}
+
+// deleteMatchingSegments prunes [from, to) out of every segment in keys via
+// deleteSegmentRange, then garbage-collects the dictionary of each
+// affected app once none of its segments (matched or not) reference it
+// any more.
+func (s *Storage) deleteMatchingSegments(keys [][]byte, from, to time.Time) error {
+	affectedApps := make(map[string]bool)
+	for _, kb := range keys {
+		key, err := segment.ParseKey(string(kb))
+		if err != nil {
+			return fmt.Errorf("storage: delete: %w", err)
+		}
+		if _, err := s.deleteSegmentRange(key, from, to, false); err != nil {
+			return err
+		}
+		affectedApps[key.AppName()] = true
+	}
+	for appname := range affectedApps {
+		if _, ok := s.lookupAppDimension(appname); ok {
+			continue
+		}
+		if err := s.dicts.Delete(appname); err != nil {
+			return fmt.Errorf("storage: delete dict: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteBy removes every segment matching selector — a possibly partial
+// key such as `my.app.cpu{foo=bar}` or just `{env=staging}` — across every
+// app and label combination it resolves to. Matching segments are found by
+// intersecting the posting lists in s.dimensions for each label (and, if
+// present, the app name) in selector, the same way lookupAppDimension
+// intersects on `__name__` alone. A dimension that still has a reference
+// from a segment outside the match set (e.g. a sibling app sharing a
+// label) is left alone, same as DeleteRange.
+func (s *Storage) DeleteBy(selector segment.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.MatchingSegments(selector)
+	if err != nil {
+		return err
+	}
+	raw := make([][]byte, len(keys))
+	for i, k := range keys {
+		raw[i] = []byte(k)
+	}
+	return s.deleteMatchingSegments(raw, time.Time{}, farFuture)
+}
+
+// MatchingSegments returns the segment keys that selector resolves to,
+// without deleting anything. DeleteBy uses it to compute its delete set;
+// the admin API uses it to report how many segments an operation will
+// touch before running it.
+func (s *Storage) MatchingSegments(selector segment.Key) ([]string, error) {
+	pairs := selector.DimensionPairs()
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("storage: selector must specify an app name or at least one label")
+	}
+
+	dims := make([]*dimension.Dimension, 0, len(pairs))
+	for _, p := range pairs {
+		v, ok := s.dimensions.Cache.Lookup(p.DimensionKey())
+		if !ok {
+			// No segment carries this label at all, so nothing can match.
+			return nil, nil
+		}
+		dims = append(dims, v.(*dimension.Dimension))
+	}
+
+	matched := dimension.Intersection(dims...)
+	keys := make([]string, len(matched))
+	for i, k := range matched {
+		keys[i] = string(k)
+	}
+	return keys, nil
+}
+
+// deleteSegmentRange prunes a single segment's nodes in [from, to) and, if
+// the segment ends up empty at every depth, removes it and decrements the
+// dimension (and, transitively, label) references it held. It reports
+// whether the segment was fully removed. When reap is true, every entry
+// actually evicted is counted against the expired_*_total metrics instead
+// of being treated as an explicit operator delete.
+func (s *Storage) deleteSegmentRange(key segment.Key, from, to time.Time, reap bool) (bool, error) {
+	return s.pruneSegment(key, reap, func(sg *segment.Segment, cb func(depth int, t time.Time)) {
+		sg.DeleteRange(from, to, cb)
+	})
+}
+
+// deleteSegmentNode prunes a single (depth, t) bucket out of key's segment,
+// as opposed to deleteSegmentRange's whole-time-window prune. The reaper
+// uses this so that expiring one depth's bucket can never delete a
+// sibling depth's bucket whose timestamp happens to coincide (coarser
+// depths' bucket boundaries are multiples of finer ones).
+func (s *Storage) deleteSegmentNode(key segment.Key, depth int, t time.Time) (bool, error) {
+	return s.pruneSegment(key, true, func(sg *segment.Segment, cb func(depth int, t time.Time)) {
+		sg.DeleteNode(depth, t, cb)
+	})
+}
+
+func (s *Storage) pruneSegment(key segment.Key, reap bool, prune func(sg *segment.Segment, cb func(depth int, t time.Time))) (bool, error) {
+	segmentKey := key.SegmentKey()
+	v, ok := s.segments.Cache.Lookup(segmentKey)
+	if !ok {
+		return true, nil
+	}
+	sg := v.(*segment.Segment)
+
+	var deleteErr error
+	prune(sg, func(depth int, t time.Time) {
+		// Depths >= 1 are presence-only: there's no tree to evict, just
+		// the segment's own bookkeeping which prune() already removed.
+		if depth != 0 {
+			return
+		}
+		treeKey := key.TreeKey(depth, t)
+		if err := s.trees.Delete(treeKey); err != nil && deleteErr == nil {
+			deleteErr = fmt.Errorf("storage: delete tree: %w", err)
+			return
+		}
+		s.expiry.remove(treeKey)
+		if reap {
+			s.metrics.expiredTreesTotal.Inc()
+		}
+	})
+	if deleteErr != nil {
+		return false, deleteErr
+	}
+	if !sg.IsEmpty() {
+		return false, nil
+	}
+
+	if err := s.segments.Delete(segmentKey); err != nil {
+		return false, fmt.Errorf("storage: delete segment: %w", err)
+	}
+	if reap {
+		s.metrics.expiredSegmentsTotal.Inc()
+	}
+	for _, pair := range key.DimensionPairs() {
+		dk := pair.DimensionKey()
+		v, ok := s.dimensions.Cache.Lookup(dk)
+		if !ok {
+			continue
+		}
+		dm := v.(*dimension.Dimension)
+		dm.Delete([]byte(segmentKey))
+		if !dm.IsEmpty() {
+			continue
+		}
+		if err := s.dimensions.Delete(dk); err != nil {
+			return false, fmt.Errorf("storage: delete dimension: %w", err)
+		}
+		if reap {
+			s.metrics.expiredDimensionsTotal.Inc()
+		}
+		s.labels.Delete(pair.Name, pair.Value)
+	}
+	return true, nil
+}
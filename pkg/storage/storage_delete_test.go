@@ -331,10 +331,183 @@ var _ = Describe("storage package", func() {
 			})
 		})
 
+		Context("bounded delete (DeleteRange)", func() {
+			It("only prunes the requested time window, retaining the rest", func() {
+				appname := "my.app.cpu"
+
+				tree1 := tree.New()
+				tree1.Insert([]byte("a;b"), uint64(1))
+
+				key, _ := segment.ParseKey(appname)
+
+				// Two disjoint windows for the same segment: one we'll
+				// delete, one we expect to survive untouched.
+				oldStart, oldEnd := testing.SimpleTime(10), testing.SimpleTime(19)
+				newStart, newEnd := testing.SimpleTime(100), testing.SimpleTime(109)
+
+				Expect(s.Put(&PutInput{
+					StartTime:  oldStart,
+					EndTime:    oldEnd,
+					Key:        key,
+					Val:        tree1,
+					SpyName:    "testspy",
+					SampleRate: 100,
+				})).ToNot(HaveOccurred())
+
+				Expect(s.Put(&PutInput{
+					StartTime:  newStart,
+					EndTime:    newEnd,
+					Key:        key,
+					Val:        tree1,
+					SpyName:    "testspy",
+					SampleRate: 100,
+				})).ToNot(HaveOccurred())
+
+				checkTreesPresence(appname, oldStart, 0, true)
+				checkTreesPresence(appname, newStart, 0, true)
+
+				By("deleting only the old window")
+				err := s.DeleteRange(appname, oldStart, oldEnd.Add(time.Second))
+				Expect(err).ToNot(HaveOccurred())
+
+				By("the old window's trees are gone")
+				checkTreesPresence(appname, oldStart, 0, false)
+
+				By("the new window's trees, and the segment itself, survive")
+				checkTreesPresence(appname, newStart, 0, true)
+				checkSegmentsPresence(appname, true)
+				checkDimensionsPresence(appname, true)
+				checkLabelsPresence(appname, true)
+			})
+		})
+
+		Context("concurrent put during delete", func() {
+			It("serializes against the in-flight delete without corrupting state", func() {
+				appname := "my.app.cpu"
+
+				tree1 := tree.New()
+				tree1.Insert([]byte("a;b"), uint64(1))
+
+				key, _ := segment.ParseKey(appname)
+				st := testing.SimpleTime(10)
+				et := testing.SimpleTime(19)
+
+				Expect(s.Put(&PutInput{
+					StartTime:  st,
+					EndTime:    et,
+					Key:        key,
+					Val:        tree1,
+					SpyName:    "testspy",
+					SampleRate: 100,
+				})).ToNot(HaveOccurred())
+
+				done := make(chan error, 1)
+				go func() {
+					done <- s.Put(&PutInput{
+						StartTime:  testing.SimpleTime(200),
+						EndTime:    testing.SimpleTime(209),
+						Key:        key,
+						Val:        tree1,
+						SpyName:    "testspy",
+						SampleRate: 100,
+					})
+				}()
+
+				Expect(s.DeleteApp(appname)).ToNot(HaveOccurred())
+				Expect(<-done).ToNot(HaveOccurred())
+
+				// Whichever happened last, the cache must reflect exactly
+				// one consistent outcome rather than a half-applied delete.
+				_, segmentPresent := s.segments.Cache.Lookup(key.SegmentKey())
+				if segmentPresent {
+					checkTreesPresence(appname, testing.SimpleTime(200), 0, true)
+				} else {
+					checkDimensionsPresence(appname, false)
+				}
+			})
+		})
+
 		// In this test we have 2 apps with the same label
 		// And deleting one app should not interfer with the labels of the other app
 		Context("multiple apps with labels", func() {
 			It("works correctly", func() {
+				app1 := "my.app1.cpu"
+				app2 := "my.app2.cpu"
+
+				tree1 := tree.New()
+				tree1.Insert([]byte("a;b"), uint64(1))
+
+				st := testing.SimpleTime(10)
+				et := testing.SimpleTime(19)
+
+				// Both apps share foo=bar, but only app1 carries
+				// function=slow.
+				put := func(appname, labels string) {
+					key, err := segment.ParseKey(appname + labels)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(s.Put(&PutInput{
+						StartTime:  st,
+						EndTime:    et,
+						Key:        key,
+						Val:        tree1,
+						SpyName:    "testspy",
+						SampleRate: 100,
+					})).ToNot(HaveOccurred())
+				}
+				put(app1, "{foo=bar,function=slow}")
+				put(app2, "{foo=bar,function=fast}")
+
+				By("deleting only app1's labeled segment via DeleteBy")
+				selector, err := segment.ParseKey(app1 + "{function=slow}")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s.DeleteBy(selector)).ToNot(HaveOccurred())
+
+				By("app1's segment and labels are gone")
+				_, app1SegmentPresent := s.segments.Cache.Lookup(app1 + "{foo=bar,function=slow}")
+				Expect(app1SegmentPresent).To(BeFalse())
+				checkDimensionsPresence(app1, false)
+
+				By("app2's foo=bar dimension and label survive")
+				_, app2SegmentPresent := s.segments.Cache.Lookup(app2 + "{foo=bar,function=fast}")
+				Expect(app2SegmentPresent).To(BeTrue())
+				checkDimensionsPresence(app2, true)
+				_, fooBarPresent := s.dimensions.Cache.Lookup("foo:bar")
+				Expect(fooBarPresent).To(BeTrue())
+				checkLabelsPresence(app2, true)
+			})
+		})
+
+		Context("DeleteBy with a label-only selector", func() {
+			It("deletes every segment carrying the label, across apps", func() {
+				app1 := "my.app1.cpu"
+				app2 := "my.app2.cpu"
+
+				tree1 := tree.New()
+				tree1.Insert([]byte("a;b"), uint64(1))
+				st := testing.SimpleTime(10)
+				et := testing.SimpleTime(19)
+
+				for _, appname := range []string{app1, app2} {
+					key, err := segment.ParseKey(appname + "{env=staging}")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(s.Put(&PutInput{
+						StartTime:  st,
+						EndTime:    et,
+						Key:        key,
+						Val:        tree1,
+						SpyName:    "testspy",
+						SampleRate: 100,
+					})).ToNot(HaveOccurred())
+				}
+
+				selector, err := segment.ParseKey("{env=staging}")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s.DeleteBy(selector)).ToNot(HaveOccurred())
+
+				checkDimensionsPresence(app1, false)
+				checkDimensionsPresence(app2, false)
+				_, envPresent := s.dimensions.Cache.Lookup("env:staging")
+				Expect(envPresent).To(BeFalse())
 			})
 		})
 	})
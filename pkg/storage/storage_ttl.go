@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+)
+
+// treeRef is the bookkeeping the reaper needs to turn an expired tree cache
+// key back into the (segment, depth, bucket time) it belongs to, so it can
+// drive the same GC cascade DeleteRange uses.
+type treeRef struct {
+	Key   segment.Key
+	Depth int
+	Time  time.Time
+}
+
+// expiryIndex tracks, for every tree entry written with a TTL, which
+// segment/depth/bucket it came from. The tree cache itself only knows
+// about expiration instants; this is the side table that lets reaping
+// translate an expired key into a deletion.
+type expiryIndex struct {
+	mu    sync.Mutex
+	trees map[string]treeRef
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{trees: make(map[string]treeRef)}
+}
+
+func (e *expiryIndex) put(treeKey string, ref treeRef) {
+	e.mu.Lock()
+	e.trees[treeKey] = ref
+	e.mu.Unlock()
+}
+
+func (e *expiryIndex) lookup(treeKey string) (treeRef, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ref, ok := e.trees[treeKey]
+	return ref, ok
+}
+
+func (e *expiryIndex) remove(treeKey string) {
+	e.mu.Lock()
+	delete(e.trees, treeKey)
+	e.mu.Unlock()
+}
+
+// expiryMetrics are the Prometheus counters the reaper reports eviction
+// activity through.
+type expiryMetrics struct {
+	expiredTreesTotal      prometheus.Counter
+	expiredSegmentsTotal   prometheus.Counter
+	expiredDimensionsTotal prometheus.Counter
+	expiredDictsTotal      prometheus.Counter
+}
+
+func newExpiryMetrics(reg prometheus.Registerer) *expiryMetrics {
+	f := promauto.With(reg)
+	return &expiryMetrics{
+		expiredTreesTotal: f.NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_storage_expired_trees_total",
+			Help: "Number of tree entries evicted by the TTL reaper.",
+		}),
+		expiredSegmentsTotal: f.NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_storage_expired_segments_total",
+			Help: "Number of segments removed by the TTL reaper because they became empty.",
+		}),
+		expiredDimensionsTotal: f.NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_storage_expired_dimensions_total",
+			Help: "Number of dimension entries removed by the TTL reaper because they lost their last reference.",
+		}),
+		expiredDictsTotal: f.NewCounter(prometheus.CounterOpts{
+			Name: "pyroscope_storage_expired_dicts_total",
+			Help: "Number of app dictionaries removed by the TTL reaper because no segment referenced them any more.",
+		}),
+	}
+}
+
+// expireTimeFor resolves the absolute expiry a Put should record, in
+// priority order: an explicit ExpireTime, a per-Put TTL, then the app's
+// configured TTL (DefaultTTL, overridden by a matching glob in
+// TTLOverrides; which one, if several match, is not defined). A zero
+// result means "never expires".
+func (s *Storage) expireTimeFor(po *PutInput) time.Time {
+	if !po.ExpireTime.IsZero() {
+		return po.ExpireTime
+	}
+	now := s.clock.Now()
+	if po.TTL > 0 {
+		return now.Add(po.TTL)
+	}
+	if ttl := s.ttlForApp(po.Key.AppName()); ttl > 0 {
+		return now.Add(ttl)
+	}
+	return time.Time{}
+}
+
+func (s *Storage) ttlForApp(appname string) time.Duration {
+	for pattern, ttl := range s.config.TTLOverrides {
+		if ok, _ := path.Match(pattern, appname); ok {
+			return ttl
+		}
+	}
+	return s.config.DefaultTTL
+}
+
+// reapLoop periodically scans for expired data until the Storage is
+// closed.
+func (s *Storage) reapLoop() {
+	ticker := s.clock.Ticker(s.config.reapInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.reapExpired(s.clock.Now())
+		}
+	}
+}
+
+// reapExpired evicts every tree whose TTL has passed as of now, cascading
+// into segment/dimension/dict/label cleanup exactly as DeleteRange does
+// when a segment becomes empty.
+func (s *Storage) reapExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apps := make(map[string]bool)
+	for _, treeKey := range s.trees.ExpiredKeys(now) {
+		ref, ok := s.expiry.lookup(treeKey)
+		if !ok {
+			// Not a TTL'd entry we know about (or already reaped); fall
+			// back to a plain cache delete.
+			s.trees.Delete(treeKey)
+			continue
+		}
+		if _, err := s.deleteSegmentNode(ref.Key, ref.Depth, ref.Time); err != nil {
+			s.logger.WithError(err).WithField("tree_key", treeKey).Warn("storage: failed to reap expired tree")
+			continue
+		}
+		apps[ref.Key.AppName()] = true
+	}
+
+	for appname := range apps {
+		if _, ok := s.lookupAppDimension(appname); ok {
+			continue
+		}
+		_, existed := s.dicts.Cache.Lookup(appname)
+		if err := s.dicts.Delete(appname); err == nil && existed {
+			s.metrics.expiredDictsTotal.Inc()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+	"github.com/pyroscope-io/pyroscope/pkg/testing"
+)
+
+var _ = Describe("expiring writes", func() {
+	var s *Storage
+	var mockClock *clock.Mock
+
+	testing.WithConfig(func(cfg **config.Config) {
+		JustBeforeEach(func() {
+			var err error
+			mockClock = clock.NewMock()
+			storageConfig := NewConfig(&(*cfg).Server)
+			storageConfig.DefaultTTL = 10 * time.Minute
+			storageConfig.ReapInterval = time.Minute
+			s, err = newStorage(storageConfig, logrus.StandardLogger(), prometheus.NewRegistry(), mockClock)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("app with a default TTL", func() {
+		It("is reaped once the TTL elapses, without an explicit DeleteApp", func() {
+			appname := "my.app.cpu"
+
+			tree1 := tree.New()
+			tree1.Insert([]byte("a;b"), uint64(1))
+
+			key, _ := segment.ParseKey(appname)
+			st := testing.SimpleTime(10)
+			et := testing.SimpleTime(19)
+
+			err := s.Put(&PutInput{
+				StartTime:  st,
+				EndTime:    et,
+				Key:        key,
+				Val:        tree1,
+				SpyName:    "testspy",
+				SampleRate: 100,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			treeKey := key.TreeKey(0, st)
+			_, ok := s.trees.Cache.Lookup(treeKey)
+			Expect(ok).To(BeTrue())
+
+			By("advancing the clock past the TTL and reaping")
+			mockClock.Add(11 * time.Minute)
+			s.reapExpired(mockClock.Now())
+
+			_, ok = s.trees.Cache.Lookup(treeKey)
+			Expect(ok).To(BeFalse())
+			_, ok = s.segments.Cache.Lookup(key.SegmentKey())
+			Expect(ok).To(BeFalse())
+			_, ok = s.dimensions.Cache.Lookup("__name__:" + appname)
+			Expect(ok).To(BeFalse())
+			_, ok = s.dicts.Cache.Lookup(appname)
+			Expect(ok).To(BeFalse())
+
+			found := false
+			for _, v := range s.GetAppNames() {
+				if v == appname {
+					found = true
+				}
+			}
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Context("per-Put TTL overriding the default", func() {
+		It("expires on its own schedule", func() {
+			appname := "my.app.cpu"
+			key, _ := segment.ParseKey(appname)
+			tree1 := tree.New()
+
+			err := s.Put(&PutInput{
+				StartTime:  testing.SimpleTime(10),
+				EndTime:    testing.SimpleTime(19),
+				Key:        key,
+				Val:        tree1,
+				SpyName:    "testspy",
+				SampleRate: 100,
+				TTL:        time.Minute,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			mockClock.Add(2 * time.Minute)
+			s.reapExpired(mockClock.Now())
+
+			_, ok := s.trees.Cache.Lookup(key.TreeKey(0, testing.SimpleTime(10)))
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,50 @@
+// Package tree implements the flame graph tree that Storage persists for
+// every segment/depth/time bucket.
+package tree
+
+import "bytes"
+
+// Tree is a profiling tree: each node is a stack frame, annotated with the
+// number of samples (Total) observed along that path.
+type Tree struct {
+	root *node
+}
+
+type node struct {
+	name     []byte
+	total    uint64
+	self     uint64
+	children []*node
+}
+
+// New returns an empty Tree.
+func New() *Tree {
+	return &Tree{root: &node{}}
+}
+
+// Insert adds value samples for the given semicolon-delimited stacktrace.
+func (t *Tree) Insert(stacktrace []byte, value uint64) {
+	n := t.root
+	n.total += value
+	for _, frame := range bytes.Split(stacktrace, []byte(";")) {
+		n = n.child(frame)
+		n.total += value
+	}
+	n.self += value
+}
+
+func (n *node) child(name []byte) *node {
+	for _, c := range n.children {
+		if bytes.Equal(c.name, name) {
+			return c
+		}
+	}
+	c := &node{name: append([]byte(nil), name...)}
+	n.children = append(n.children, c)
+	return c
+}
+
+// Samples returns the total number of samples recorded in the tree.
+func (t *Tree) Samples() uint64 {
+	return t.root.total
+}
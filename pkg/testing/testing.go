@@ -0,0 +1,43 @@
+// Package testing provides small helpers shared by Ginkgo specs across the
+// codebase.
+package testing
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+
+	"github.com/pyroscope-io/pyroscope/pkg/config"
+)
+
+// WithConfig runs a BeforeEach/AfterEach pair that points cfg at a
+// throwaway config.Config backed by a fresh temp directory for the
+// duration of each spec, and removes the directory afterwards.
+func WithConfig(fn func(cfg **config.Config)) {
+	var cfg *config.Config
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pyroscope-test")
+		if err != nil {
+			panic(err)
+		}
+		cfg = &config.Config{Server: config.Server{StoragePath: dir}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	fn(&cfg)
+}
+
+// SimpleTime returns a fixed, deterministic time offset by n minutes from a
+// fixed epoch, used so specs can reason about bucket boundaries without
+// depending on wall-clock time.
+func SimpleTime(n int) time.Time {
+	return time.Date(2021, 1, 1, 0, n, 0, 0, time.UTC)
+}